@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"example.org/config"
+)
+
+// runCheck connects, runs opts.SQL, and prints the Nagios result line for
+// the "query" subcommand and every canned-query subcommand (replication,
+// blocked, alwayson). regex is only applied by "query"; canned modes pass
+// an empty string.
+func runCheck(opts config.QueryOptions, regex string) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.AppConfig.Timeout)*time.Second)
+	defer cancel()
+
+	resultChan := make(chan *queryResult, 1)
+	errChan := make(chan error, 1)
+
+	go func() {
+		db, err := connect(ctx)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		defer db.Close()
+
+		maxRows, err := parseRowsFlag(opts.Rows)
+		if err != nil {
+			errChan <- err
+			return
+		}
+
+		rr, err := execute(ctx, db, opts.SQL, maxRows, config.AppConfig.Session)
+		if err != nil {
+			errChan <- err
+			return
+		}
+
+		result, err := buildQueryResult(rr, opts.PerfdataCols, opts.LabelCol, opts.ValueCol, opts.Warning, opts.Critical)
+		if err != nil {
+			errChan <- err
+			return
+		}
+		resultChan <- result
+	}()
+
+	select {
+	case result := <-resultChan:
+		if opts.Warning != "" || opts.Critical != "" {
+			if result.Value == nil {
+				msg := "no numeric column found to evaluate --warning/--critical against"
+				if opts.ValueCol != "" {
+					msg = fmt.Sprintf("column %q not found or not numeric", opts.ValueCol)
+				}
+				fmt.Printf("SQL UNKNOWN: %s\n", msg)
+				os.Exit(UNKNOWN)
+			}
+
+			status, perfEntry, err := evaluateThreshold(result.ValueCol, *result.Value, opts.Warning, opts.Critical)
+			if err != nil {
+				fmt.Printf("SQL UNKNOWN: %v\n", err)
+				os.Exit(UNKNOWN)
+			}
+			result.Perfdata = strings.TrimSpace(result.Perfdata + " " + perfEntry)
+
+			switch status {
+			case CRITICAL:
+				fmt.Printf("SQL CRITICAL: %s|%s\n", result.Summary, result.Perfdata)
+				os.Exit(CRITICAL)
+			case WARNING:
+				fmt.Printf("SQL WARNING: %s|%s\n", result.Summary, result.Perfdata)
+				os.Exit(WARNING)
+			}
+		}
+
+		if regex != "" {
+			matched, err := regexp.MatchString(regex, result.Summary)
+			if err != nil {
+				fmt.Printf("SQL CRITICAL: Invalid regex: %v\n", err)
+				os.Exit(CRITICAL)
+			}
+			if matched {
+				fmt.Printf("SQL CRITICAL: %s|%s\n", result.Summary, result.Perfdata)
+				os.Exit(CRITICAL)
+			}
+		}
+
+		fmt.Printf("SQL OK: %s|%s\n", result.Summary, result.Perfdata)
+		os.Exit(OK)
+	case err := <-errChan:
+		fmt.Printf("SQL CRITICAL: %v\n", err)
+		os.Exit(CRITICAL)
+	case <-time.After(time.Duration(config.AppConfig.Timeout) * time.Second):
+		fmt.Printf("SQL UNKNOWN: ERROR connection %s (timeout after %ds)\n", config.AppConfig.Server, config.AppConfig.Timeout)
+		os.Exit(UNKNOWN)
+	}
+}
@@ -9,8 +9,113 @@ type Config struct {
 	Password string
 	Database string
 	Timeout  int
-	Query    string
-	Regex    string
+
+	// Mode is the subcommand being run (query, conn, replication, blocked,
+	// alwayson, daemon).
+	Mode string
+
+	Auth    AuthOptions
+	Session SessionOptions
+
+	Query       QueryOptions
+	Conn        ConnOptions
+	Replication ReplicationOptions
+	Blocked     BlockedOptions
+	AlwaysOn    AlwaysOnOptions
+	Daemon      DaemonOptions
+}
+
+// AuthOptions holds authentication and transport-encryption settings
+// shared by every subcommand, threaded into buildConnector().
+type AuthOptions struct {
+	// Type selects the authentication mode: "sql" (default), "kerberos"
+	// (Windows SSPI only — see flags.go's apply()), "azure-managed-identity",
+	// "azure-service-principal" or "azure-access-token".
+	Type string
+
+	TenantID     string // auth=azure-service-principal
+	ClientID     string // auth=azure-managed-identity, azure-service-principal
+	ClientSecret string // auth=azure-service-principal
+	AccessToken  string // auth=azure-access-token
+
+	// Note: there is no client-certificate auth mode. go-mssqldb v0.12.3
+	// has no mTLS code path, and its DSN "certificate"/"certificate key"
+	// params are actually the server CA bundle and an unrecognized key,
+	// not client-auth material, so one can't be faked through those.
+
+	Encrypt                string // disable|false|true (go-mssqldb v0.12.3 has no "strict" mode)
+	TrustServerCertificate bool
+	ServerCA               string
+	HostnameInCertificate  string
+}
+
+// SessionOptions controls the transaction isolation level and session
+// options a check's query runs under, so a monitoring query can't take
+// shared locks or be blocked indefinitely against a production OLTP
+// database. Shared by every subcommand.
+type SessionOptions struct {
+	// Isolation is one of "read-uncommitted", "read-committed",
+	// "snapshot", "serializable", or "" for the server default.
+	Isolation string
+	ReadOnly  bool
+	// Set is a semicolon-separated list of session-option statements
+	// (e.g. "ARITHABORT ON;LOCK_TIMEOUT 3000") executed once on the
+	// connection before the query runs.
+	Set string
+}
+
+// QueryOptions holds the options specific to the "query" subcommand, which
+// runs an arbitrary user-supplied query.
+type QueryOptions struct {
+	SQL          string
+	Regex        string
+	Rows         string
+	PerfdataCols string
+	LabelCol     string
+	ValueCol     string
+	Warning      string
+	Critical     string
+}
+
+// ConnOptions holds the options specific to the "conn" subcommand, which
+// checks how long it takes to connect to the server.
+type ConnOptions struct {
+	Warning  string
+	Critical string
+}
+
+// ReplicationOptions holds the options specific to the "replication"
+// subcommand, which checks AlwaysOn / mirroring replication lag.
+type ReplicationOptions struct {
+	Warning  string
+	Critical string
+}
+
+// BlockedOptions holds the options specific to the "blocked" subcommand,
+// which checks for blocked sessions.
+type BlockedOptions struct {
+	Warning  string
+	Critical string
+}
+
+// AlwaysOnOptions holds the options specific to the "alwayson" subcommand,
+// which checks AlwaysOn availability group replica health.
+type AlwaysOnOptions struct {
+	Warning  string
+	Critical string
+}
+
+// DaemonOptions holds the options specific to the "daemon" subcommand,
+// which keeps running and serves check results over HTTP instead of
+// exiting with a single Nagios result.
+type DaemonOptions struct {
+	Listen          string
+	ChecksFile      string
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime string
+	Retries         int
+	RetryBackoff    string
 }
 
 var AppConfig Config
@@ -0,0 +1,540 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"example.org/config"
+	"example.org/logger"
+
+	mssql "github.com/denisenkom/go-mssqldb"
+	"github.com/denisenkom/go-mssqldb/msdsn"
+
+	"go.uber.org/zap"
+
+	"check_mssql/pkg/threshold"
+)
+
+// connTarget identifies the server a connection is made to. Most
+// subcommands connect to a single target taken from config.AppConfig;
+// daemon mode may override it per check so several checks against the
+// same target can share a pooled *sql.DB.
+type connTarget struct {
+	Server   string
+	Port     int
+	Database string
+}
+
+// defaultTarget returns the connTarget described by the top-level
+// connection flags.
+func defaultTarget() connTarget {
+	return connTarget{
+		Server:   config.AppConfig.Server,
+		Port:     config.AppConfig.Port,
+		Database: config.AppConfig.Database,
+	}
+}
+
+// buildDSN assembles the go-mssqldb connection string for target under
+// the current config.AppConfig.Auth mode, along with a redacted copy
+// (secrets masked) that's safe to log.
+func buildDSN(target connTarget) (dsn, logDSN string) {
+	auth := config.AppConfig.Auth
+
+	base := fmt.Sprintf("server=%s;port=%d;connection timeout=%d",
+		target.Server, target.Port, config.AppConfig.Timeout)
+	if target.Database != "" {
+		base += fmt.Sprintf(";database=%s", target.Database)
+	}
+	if auth.Encrypt != "" {
+		base += fmt.Sprintf(";encrypt=%s", auth.Encrypt)
+	}
+	if auth.TrustServerCertificate {
+		base += ";TrustServerCertificate=true"
+	}
+	if auth.ServerCA != "" {
+		base += fmt.Sprintf(";certificate=%s", auth.ServerCA)
+	}
+	if auth.HostnameInCertificate != "" {
+		base += fmt.Sprintf(";hostNameInCertificate=%s", auth.HostnameInCertificate)
+	}
+
+	switch auth.Type {
+	case "kerberos":
+		// Integrated auth: omitting user id/password makes go-mssqldb try
+		// SSPI using the host's ambient ticket. Windows-only; apply()
+		// rejects this mode on every other OS.
+		return base, base
+	case "azure-managed-identity":
+		dsn := base + ";fedauth=ActiveDirectoryMSI"
+		if auth.ClientID != "" {
+			dsn += fmt.Sprintf(";user id=%s", auth.ClientID)
+		}
+		return dsn, dsn
+	case "azure-service-principal":
+		dsn := base + fmt.Sprintf(";fedauth=ActiveDirectoryServicePrincipal;tenant id=%s;user id=%s;password=%s",
+			auth.TenantID, auth.ClientID, auth.ClientSecret)
+		log := base + fmt.Sprintf(";fedauth=ActiveDirectoryServicePrincipal;tenant id=%s;user id=%s;password=***",
+			auth.TenantID, auth.ClientID)
+		return dsn, log
+	case "azure-access-token":
+		// The access token isn't a DSN parameter: buildConnector wraps base
+		// in a security-token connector instead of passing this string to
+		// mssql.NewConnector, so there's nothing to redact here.
+		return base, base
+	default: // "sql"
+		dsn := base + fmt.Sprintf(";user id=%s;password=%s", config.AppConfig.Username, config.AppConfig.Password)
+		log := base + fmt.Sprintf(";user id=%s;password=***", config.AppConfig.Username)
+		return dsn, log
+	}
+}
+
+// buildConnector builds a driver.Connector for target, rather than a
+// logged DSN string, so credentials never appear in the log.
+func buildConnector(target connTarget) (driver.Connector, error) {
+	dsn, logDSN := buildDSN(target)
+	logger.Log.Debug("connecting to database",
+		zap.String("connString", logDSN),
+	)
+
+	if config.AppConfig.Auth.Type == "azure-access-token" {
+		return buildAccessTokenConnector(dsn)
+	}
+
+	connector, err := mssql.NewConnector(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid connection parameters: %v", err)
+	}
+	return connector, nil
+}
+
+// buildAccessTokenConnector builds a connector that authenticates with a
+// pre-obtained Azure AD access token (-A azure-access-token), using
+// go-mssqldb's security-token fedauth path rather than the
+// "fedauth=ActiveDirectoryServicePrincipal" DSN parameter, since a bearer
+// token isn't a password and the driver has no DSN syntax for it.
+func buildAccessTokenConnector(dsn string) (driver.Connector, error) {
+	msdsnConfig, _, err := msdsn.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid connection parameters: %v", err)
+	}
+	token := config.AppConfig.Auth.AccessToken
+	connector, err := mssql.NewSecurityTokenConnector(msdsnConfig, func(ctx context.Context) (string, error) {
+		return token, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid connection parameters: %v", err)
+	}
+	return connector, nil
+}
+
+// connect opens a connection to the server described by config.AppConfig
+// and verifies it with a ping. Every one-shot check subcommand shares
+// this; daemon mode uses a connPool instead so it can reuse connections
+// across checks.
+func connect(ctx context.Context) (*sql.DB, error) {
+	connector, err := buildConnector(defaultTarget())
+	if err != nil {
+		logger.Log.Warn("failed to run command",
+			zap.Error(err),
+		)
+		return nil, fmt.Errorf("can't connect to server: %v", err)
+	}
+
+	db := sql.OpenDB(connector)
+
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		logger.Log.Warn("can't ping server",
+			zap.Error(err),
+		)
+		return nil, fmt.Errorf("can't ping server: %v", err)
+	}
+	return db, nil
+}
+
+// rawRows holds the columns, their SQL types, and every scanned row
+// returned by execute.
+type rawRows struct {
+	Cols     []string
+	ColTypes []*sql.ColumnType
+	Values   [][]interface{}
+}
+
+// col returns the index of the named column, or -1 if it isn't present.
+func (r *rawRows) col(name string) int {
+	for i, c := range r.Cols {
+		if c == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// parseIsolation maps a --isolation flag value to a sql.IsolationLevel.
+func parseIsolation(s string) (sql.IsolationLevel, error) {
+	switch strings.ToLower(s) {
+	case "":
+		return sql.LevelDefault, nil
+	case "read-uncommitted":
+		return sql.LevelReadUncommitted, nil
+	case "read-committed":
+		return sql.LevelReadCommitted, nil
+	case "snapshot":
+		return sql.LevelSnapshot, nil
+	case "serializable":
+		return sql.LevelSerializable, nil
+	default:
+		return sql.LevelDefault, fmt.Errorf("unknown --isolation value %q", s)
+	}
+}
+
+// validateEncrypt checks that s is an --encrypt value go-mssqldb v0.12.3
+// actually understands: its DSN parser (msdsn/conn_str.go) only recognizes
+// "disable" or a strconv.ParseBool value, with no "strict" mode in this
+// driver version, so catching an unsupported value here gives a clear
+// error instead of a late, opaque "invalid connection parameters" failure
+// from inside buildConnector.
+func validateEncrypt(s string) error {
+	if s == "" || strings.EqualFold(s, "disable") {
+		return nil
+	}
+	if _, err := strconv.ParseBool(s); err != nil {
+		return fmt.Errorf("unknown --encrypt value %q: must be disable, false or true", s)
+	}
+	return nil
+}
+
+// sessionTxOptions builds the *sql.TxOptions execute should run the query
+// under, or nil if the session is left at the server's defaults.
+//
+// session.ReadOnly is intentionally never forwarded as TxOptions.ReadOnly:
+// go-mssqldb's Conn.BeginTx rejects any TxOptions with ReadOnly set
+// ("read-only transactions are not supported"), so doing so would fail
+// every --read-only query outright. --read-only still has an effect: it
+// forces the query onto an explicit transaction (even with no
+// --isolation set) that execute always rolls back, which is what
+// actually keeps a monitoring query from leaving writes behind.
+func sessionTxOptions(session config.SessionOptions) (*sql.TxOptions, error) {
+	if session.Isolation == "" && !session.ReadOnly {
+		return nil, nil
+	}
+	level, err := parseIsolation(session.Isolation)
+	if err != nil {
+		return nil, err
+	}
+	return &sql.TxOptions{Isolation: level}, nil
+}
+
+// execute runs sqlText against db and scans up to maxRows rows (maxRows <
+// 0 means all rows). session.Set, the transaction, and the query all run
+// on a single connection checked out of db's pool for the duration of the
+// call: database/sql gives no session affinity across separate *sql.DB
+// calls, so running them independently could let a "SET ARITHABORT
+// ON/LOCK_TIMEOUT" statement land on a different pooled connection than
+// the one that runs the query. If session.Set is non-empty it's run once
+// on that connection first; if session.Isolation/ReadOnly request it, the
+// query runs inside an explicit isolated transaction that's always rolled
+// back afterwards, so a monitoring query can't leave writes or locks
+// behind.
+func execute(ctx context.Context, db *sql.DB, sqlText string, maxRows int, session config.SessionOptions) (*rawRows, error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error acquiring connection: %v", err)
+	}
+	defer conn.Close()
+
+	if session.Set != "" {
+		if _, err := conn.ExecContext(ctx, session.Set); err != nil {
+			return nil, fmt.Errorf("error applying --set session options: %v", err)
+		}
+	}
+
+	txOpts, err := sessionTxOptions(session)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows *sql.Rows
+	if txOpts != nil {
+		tx, err := conn.BeginTx(ctx, txOpts)
+		if err != nil {
+			return nil, fmt.Errorf("error starting transaction: %v", err)
+		}
+		defer tx.Rollback()
+		rows, err = tx.QueryContext(ctx, sqlText)
+		if err != nil {
+			logger.Log.Warn("query error", zap.Error(err))
+			return nil, fmt.Errorf("query error: %v", err)
+		}
+	} else {
+		rows, err = conn.QueryContext(ctx, sqlText)
+		if err != nil {
+			logger.Log.Warn("query error",
+				zap.Error(err),
+			)
+			return nil, fmt.Errorf("query error: %v", err)
+		}
+	}
+	defer rows.Close()
+
+	colTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, fmt.Errorf("error getting column types: %v", err)
+	}
+	cols := make([]string, len(colTypes))
+	for i, ct := range colTypes {
+		cols[i] = ct.Name()
+	}
+
+	result := &rawRows{Cols: cols, ColTypes: colTypes}
+	count := 0
+	for rows.Next() {
+		if maxRows >= 0 && count >= maxRows {
+			break
+		}
+		values := make([]interface{}, len(cols))
+		valuePtrs := make([]interface{}, len(cols))
+		for i := range values {
+			valuePtrs[i] = &values[i]
+		}
+		if err := rows.Scan(valuePtrs...); err != nil {
+			return nil, fmt.Errorf("error scanning row: %v", err)
+		}
+		result.Values = append(result.Values, values)
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading rows: %v", err)
+	}
+	return result, nil
+}
+
+// queryResult holds the human-readable summary and the Nagios perfdata
+// string built from a rawRows, plus the column selected for threshold
+// evaluation.
+type queryResult struct {
+	RowCount int
+	Summary  string
+	Perfdata string
+
+	// ValueCol and Value identify the column used for threshold evaluation
+	// (-w/-c). For multi-row results, Value is taken from whichever row
+	// evaluates worst against warning/critical (ties keep the first row),
+	// so a single unhealthy row among many still alerts. Value is nil if
+	// no numeric column was found.
+	ValueCol string
+	Value    *float64
+}
+
+// buildQueryResult formats a rawRows into a queryResult: one summary line
+// per row, Nagios perfdata for numeric (or explicitly requested) columns,
+// and the value selected for threshold evaluation. warning/critical are
+// the same Nagios range specs the caller will later pass to
+// evaluateThreshold; they're needed here too so the threshold column can
+// be picked by severity across every row, and excluded from the generic
+// perfdata pass so it isn't emitted twice once evaluateThreshold adds its
+// own bounded entry for it.
+func buildQueryResult(rr *rawRows, perfdataCols, labelCol, valueCol, warning, critical string) (*queryResult, error) {
+	if len(rr.Values) == 0 {
+		return nil, fmt.Errorf("query returned no rows")
+	}
+
+	explicitPerfCols := make(map[string]bool)
+	for _, c := range strings.Split(perfdataCols, ",") {
+		c = strings.TrimSpace(c)
+		if c != "" {
+			explicitPerfCols[c] = true
+		}
+	}
+
+	warnRange, critRange, err := parseThresholdRanges(warning, critical)
+	if err != nil {
+		return nil, err
+	}
+	hasThreshold := warning != "" || critical != ""
+	thresholdIdx, thresholdCol := resolveThresholdColumn(rr, valueCol)
+
+	var summaries []string
+	var perfdata []string
+	var thresholdValue *float64
+	worstStatus := -1 // below OK, so the first row with a value always wins
+
+	for _, values := range rr.Values {
+		label := ""
+		if labelCol != "" {
+			if i := rr.col(labelCol); i >= 0 {
+				label = formatValue(values[i])
+			}
+		}
+
+		rowStrs := make([]string, len(rr.Cols))
+		for i, c := range rr.Cols {
+			strVal := formatValue(values[i])
+			rowStrs[i] = strVal
+
+			if c == labelCol || (hasThreshold && i == thresholdIdx) {
+				continue
+			}
+			isPerf := explicitPerfCols[c]
+			if len(explicitPerfCols) == 0 {
+				isPerf = isNumericColumn(rr.ColTypes[i])
+			}
+			if !isPerf {
+				continue
+			}
+			name := c
+			if label != "" {
+				name = label + "_" + c
+			}
+			perfdata = append(perfdata, fmt.Sprintf("%s=%s;;;;", name, strVal))
+		}
+		summaries = append(summaries, strings.Join(rowStrs, ";"))
+
+		if val := thresholdValueAt(values, thresholdIdx); val != nil {
+			if status := severity(*val, warnRange, critRange); status > worstStatus {
+				thresholdValue = val
+				worstStatus = status
+			}
+		}
+	}
+
+	result := &queryResult{RowCount: len(rr.Values), ValueCol: thresholdCol, Value: thresholdValue}
+	if result.RowCount == 1 {
+		result.Summary = summaries[0]
+	} else {
+		result.Summary = fmt.Sprintf("%d rows", result.RowCount)
+	}
+	result.Perfdata = strings.Join(perfdata, " ")
+	if result.Perfdata == "" {
+		result.Perfdata = result.Summary
+	}
+	return result, nil
+}
+
+// parseRowsFlag parses a --rows value, returning -1 to mean "all rows".
+func parseRowsFlag(raw string) (int, error) {
+	if strings.EqualFold(raw, "all") {
+		return -1, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid --rows value %q: must be a positive integer or \"all\"", raw)
+	}
+	return n, nil
+}
+
+// isNumericColumn reports whether a column's SQL Server type is a numeric
+// type suitable for Nagios perfdata.
+func isNumericColumn(ct *sql.ColumnType) bool {
+	switch strings.ToUpper(ct.DatabaseTypeName()) {
+	case "TINYINT", "SMALLINT", "INT", "BIGINT", "DECIMAL", "NUMERIC", "FLOAT", "REAL", "MONEY", "SMALLMONEY", "BIT":
+		return true
+	default:
+		return false
+	}
+}
+
+// resolveThresholdColumn picks the column index and name to evaluate
+// against --warning/--critical: the named column if valueCol is set,
+// otherwise the first numeric column. The column is the same for every
+// row of a rawRows, since they all share the same query schema. Returns
+// index -1 if no column qualifies.
+func resolveThresholdColumn(rr *rawRows, valueCol string) (int, string) {
+	for i, c := range rr.Cols {
+		if valueCol != "" {
+			if c != valueCol {
+				continue
+			}
+			return i, c
+		}
+		if isNumericColumn(rr.ColTypes[i]) {
+			return i, c
+		}
+	}
+	return -1, ""
+}
+
+// thresholdValueAt parses the numeric value of the threshold column (as
+// resolved by resolveThresholdColumn) out of one row, or nil if idx is -1
+// or the value isn't numeric.
+func thresholdValueAt(values []interface{}, idx int) *float64 {
+	if idx < 0 {
+		return nil
+	}
+	f, err := strconv.ParseFloat(formatValue(values[idx]), 64)
+	if err != nil {
+		return nil
+	}
+	return &f
+}
+
+// parseThresholdRanges parses the --warning/--critical Nagios range specs,
+// leaving either nil if its spec is empty.
+func parseThresholdRanges(warning, critical string) (warnRange, critRange *threshold.Range, err error) {
+	if warning != "" {
+		if warnRange, err = threshold.Parse(warning); err != nil {
+			return nil, nil, err
+		}
+	}
+	if critical != "" {
+		if critRange, err = threshold.Parse(critical); err != nil {
+			return nil, nil, err
+		}
+	}
+	return warnRange, critRange, nil
+}
+
+// severity reports the Nagios status (OK/WARNING/CRITICAL) value gets
+// against warnRange/critRange.
+func severity(value float64, warnRange, critRange *threshold.Range) int {
+	switch {
+	case critRange != nil && critRange.Evaluate(value):
+		return CRITICAL
+	case warnRange != nil && warnRange.Evaluate(value):
+		return WARNING
+	default:
+		return OK
+	}
+}
+
+// formatValue renders a scanned column value as a string, normalizing the
+// types go-mssqldb commonly returns (NULL, []byte, time.Time) instead of
+// falling back to a generic %v for everything.
+func formatValue(val interface{}) string {
+	switch v := val.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.Format(time.RFC3339)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// evaluateThreshold checks value against the warn/crit Nagios range specs
+// and returns the resulting Nagios status and a perfdata entry in
+// "name=value;warn;crit;min;max" form.
+func evaluateThreshold(name string, value float64, warn, crit string) (status int, perfEntry string, err error) {
+	warnRange, critRange, err := parseThresholdRanges(warn, crit)
+	if err != nil {
+		return OK, "", err
+	}
+	status = severity(value, warnRange, critRange)
+
+	min, max := "", ""
+	if critRange != nil {
+		min, max = critRange.Bound()
+	} else if warnRange != nil {
+		min, max = warnRange.Bound()
+	}
+	perfEntry = fmt.Sprintf("%s=%s;%s;%s;%s;%s", name, threshold.FormatFloat(value), warn, crit, min, max)
+	return status, perfEntry, nil
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"example.org/config"
+	"example.org/logger"
+
+	"github.com/spf13/pflag"
+)
+
+// Default timeout (matching Nagios plugin conventions)
+const defaultTimeout = 15 * time.Second
+
+// connectionFlags are the server-connection, authentication and
+// transport-encryption flags shared by every subcommand.
+type connectionFlags struct {
+	help, version, verbose     *bool
+	server, username, password *string
+	credfile, database         *string
+	port, timeout              *int
+
+	auth                             *string
+	tenantID, clientID, clientSecret *string
+	accessToken                      *string
+
+	encrypt         *string
+	trustServerCert *bool
+	serverCA        *string
+	hostnameInCert  *string
+
+	isolation  *string
+	readOnly   *bool
+	sessionSet *string
+}
+
+// bindConnectionFlags registers the shared connection, authentication and
+// encryption flags on fs.
+func bindConnectionFlags(fs *pflag.FlagSet) *connectionFlags {
+	return &connectionFlags{
+		help:     fs.BoolP("help", "h", false, "Show this help"),
+		version:  fs.BoolP("version", "V", false, "Print version information."),
+		verbose:  fs.BoolP("verbose", "v", false, "Set logging to verbose level (use caution, may expose credentials)"),
+		server:   fs.StringP("hostname", "H", "", "Host to connect to"),
+		port:     fs.IntP("port", "P", 1433, "Port"),
+		username: fs.StringP("user", "u", "", "Username to connect with"),
+		password: fs.StringP("pass", "p", "", "Password to connect with"),
+		credfile: fs.StringP("credfile", "f", "", "Credentials file (format: username=<user>\npassword=<pass>)"),
+		database: fs.StringP("database", "d", "", "Database name"),
+		timeout:  fs.IntP("timeout", "t", int(defaultTimeout.Seconds()), "Timeout in seconds"),
+
+		auth:         fs.StringP("auth", "A", "sql", "Authentication mode: sql, kerberos (Windows only), azure-managed-identity, azure-service-principal, azure-access-token"),
+		tenantID:     fs.String("tenant-id", "", "Azure AD tenant ID (-A azure-service-principal)"),
+		clientID:     fs.String("client-id", "", "Azure AD / managed-identity client ID (-A azure-managed-identity, azure-service-principal)"),
+		clientSecret: fs.String("client-secret", "", "Azure AD service principal client secret (-A azure-service-principal)"),
+		accessToken:  fs.String("access-token", "", "Azure AD access token obtained out-of-band (-A azure-access-token)"),
+
+		encrypt:         fs.String("encrypt", "", "Connection encryption: disable, false, true (go-mssqldb v0.12.3 has no \"strict\" mode)"),
+		trustServerCert: fs.Bool("trust-server-certificate", false, "Trust the server's TLS certificate without validating it"),
+		serverCA:        fs.String("server-ca", "", "Path to a CA certificate to validate the server's TLS certificate"),
+		hostnameInCert:  fs.String("hostname-in-certificate", "", "Expected hostname in the server's TLS certificate"),
+
+		isolation:  fs.String("isolation", "", "Transaction isolation level for the query: read-uncommitted, read-committed, snapshot, serializable"),
+		readOnly:   fs.Bool("read-only", false, "Run the query in an explicit transaction that is always rolled back (the transaction is not marked read-only; the locks it takes depend on --isolation)"),
+		sessionSet: fs.String("set", "", "Session option statements to run once before the query, e.g. \"ARITHABORT ON;LOCK_TIMEOUT 3000\""),
+	}
+}
+
+// apply loads credentials from --credfile if given, validates the flags
+// required by the selected --auth mode, and populates config.AppConfig.
+func (c *connectionFlags) apply() error {
+	username, password := *c.username, *c.password
+	auth := config.AuthOptions{
+		Type:                   *c.auth,
+		TenantID:               *c.tenantID,
+		ClientID:               *c.clientID,
+		ClientSecret:           *c.clientSecret,
+		AccessToken:            *c.accessToken,
+		Encrypt:                *c.encrypt,
+		TrustServerCertificate: *c.trustServerCert,
+		ServerCA:               *c.serverCA,
+		HostnameInCertificate:  *c.hostnameInCert,
+	}
+
+	if *c.credfile != "" {
+		creds, err := loadCredentials(*c.credfile)
+		if err != nil {
+			return err
+		}
+		username, password = creds.Username, creds.Password
+		if creds.AuthType != "" {
+			auth.Type = creds.AuthType
+		}
+		if creds.ClientID != "" {
+			auth.ClientID = creds.ClientID
+		}
+		if creds.ClientSecret != "" {
+			auth.ClientSecret = creds.ClientSecret
+		}
+		if creds.Token != "" {
+			auth.AccessToken = creds.Token
+		}
+		if creds.TenantID != "" {
+			auth.TenantID = creds.TenantID
+		}
+	}
+
+	if *c.server == "" {
+		return fmt.Errorf("missing required argument (server)")
+	}
+
+	switch auth.Type {
+	case "sql":
+		if username == "" || password == "" {
+			return fmt.Errorf("missing required arguments (username, password)")
+		}
+	case "kerberos":
+		// go-mssqldb only implements Kerberos/SSPI through the Windows
+		// secur32.dll bindings in sspi_windows.go; its non-Windows build
+		// (ntlm.go) has no krb5/GSSAPI code path at all, so on any other
+		// OS this would silently fall back to an anonymous SQL login
+		// instead of actually authenticating. No further credentials are
+		// required: the ambient logon session's ticket is used.
+		if runtime.GOOS != "windows" {
+			return fmt.Errorf("-A kerberos is only supported on Windows (go-mssqldb has no Kerberos support on %s)", runtime.GOOS)
+		}
+	case "azure-managed-identity":
+		// No further credentials required: relies on the host's managed
+		// identity.
+	case "azure-service-principal":
+		if auth.TenantID == "" || auth.ClientID == "" || auth.ClientSecret == "" {
+			return fmt.Errorf("-A azure-service-principal requires --tenant-id, --client-id and --client-secret")
+		}
+	case "azure-access-token":
+		if auth.AccessToken == "" {
+			return fmt.Errorf("-A azure-access-token requires --access-token")
+		}
+	default:
+		return fmt.Errorf("unknown -A/--auth value %q", auth.Type)
+	}
+
+	if _, err := parseIsolation(*c.isolation); err != nil {
+		return err
+	}
+
+	if err := validateEncrypt(*c.encrypt); err != nil {
+		return err
+	}
+
+	config.AppConfig.Verbose = *c.verbose
+	config.AppConfig.Server = *c.server
+	config.AppConfig.Port = *c.port
+	config.AppConfig.Username = username
+	config.AppConfig.Password = password
+	config.AppConfig.Database = *c.database
+	config.AppConfig.Timeout = *c.timeout
+	config.AppConfig.Auth = auth
+	config.AppConfig.Session = config.SessionOptions{
+		Isolation: *c.isolation,
+		ReadOnly:  *c.readOnly,
+		Set:       *c.sessionSet,
+	}
+
+	logger.Config()
+	return nil
+}
+
+// credentials holds the values that may be set in a --credfile.
+type credentials struct {
+	Username     string
+	Password     string
+	AuthType     string
+	Token        string
+	ClientID     string
+	ClientSecret string
+	TenantID     string
+}
+
+func loadCredentials(filename string) (*credentials, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read credentials file: %v", err)
+	}
+
+	raw := make(map[string]string)
+	lines := strings.Split(string(data), "\n")
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		key := strings.TrimSpace(parts[0])
+		value := strings.TrimSpace(parts[1])
+		raw[key] = value
+	}
+
+	creds := &credentials{
+		Username:     raw["username"],
+		Password:     raw["password"],
+		AuthType:     raw["auth_type"],
+		Token:        raw["token"],
+		ClientID:     raw["client_id"],
+		ClientSecret: raw["client_secret"],
+		TenantID:     raw["tenant_id"],
+	}
+
+	if creds.AuthType == "" || creds.AuthType == "sql" {
+		if creds.Username == "" {
+			return nil, fmt.Errorf("credentials file missing username")
+		}
+		if creds.Password == "" {
+			return nil, fmt.Errorf("credentials file missing password")
+		}
+	}
+	return creds, nil
+}
+
+// preprocessArgs rewrites short flags like "-t10" into "-t 10" so pflag can
+// parse them.
+func preprocessArgs(args []string) []string {
+	var newArgs []string
+	for _, arg := range args {
+		if len(arg) > 2 && arg[0] == '-' && arg[1] != '-' && !strings.Contains(arg, "=") {
+			flagName := arg[:2]
+			flagValue := arg[2:]
+			newArgs = append(newArgs, flagName, flagValue)
+		} else {
+			newArgs = append(newArgs, arg)
+		}
+	}
+	return newArgs
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"example.org/config"
+
+	"github.com/spf13/pflag"
+)
+
+// alwaysOnSQL reports each availability replica's synchronization health.
+// synchronization_health is 0 (NOT_HEALTHY), 1 (PARTIALLY_HEALTHY) or 2
+// (HEALTHY).
+const alwaysOnSQL = `SELECT ar.replica_server_name, ars.role_desc, ars.synchronization_health_desc, ars.synchronization_health FROM sys.dm_hadr_availability_replica_states ars JOIN sys.availability_replicas ar ON ar.replica_id = ars.replica_id`
+
+// runAlwaysOnMode implements "check_mssql alwayson ...": check AlwaysOn
+// availability group replica health via
+// sys.dm_hadr_availability_replica_states.
+func runAlwaysOnMode(args []string) {
+	fs := pflag.NewFlagSet("alwayson", pflag.ExitOnError)
+	conn := bindConnectionFlags(fs)
+	warning := fs.StringP("warning", "w", "", "Warning threshold for synchronization_health (Nagios range format)")
+	critical := fs.StringP("critical", "c", "2:", "Critical threshold for synchronization_health (default alerts below HEALTHY)")
+
+	fs.Usage = func() {
+		fmt.Printf(`check_mssql alwayson - Checks AlwaysOn availability group replica health (sys.dm_hadr_availability_replica_states)
+
+Syntax: check_mssql alwayson -H <server> -u <username> -p <password> [-d <database>] [-P <port>] [-t <timeout>] [-w <warning>] [-c <critical>] [-v] [-h] [-V]
+
+`)
+		fmt.Println("Parameters:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *conn.version {
+		printVersion()
+		os.Exit(OK)
+	}
+	if *conn.help {
+		fs.Usage()
+		os.Exit(OK)
+	}
+
+	if err := conn.apply(); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		fs.Usage()
+		os.Exit(UNKNOWN)
+	}
+	config.AppConfig.AlwaysOn = config.AlwaysOnOptions{Warning: *warning, Critical: *critical}
+
+	opts := config.QueryOptions{
+		SQL:      alwaysOnSQL,
+		Rows:     "all",
+		LabelCol: "replica_server_name",
+		ValueCol: "synchronization_health",
+		Warning:  *warning,
+		Critical: *critical,
+	}
+	runCheck(opts, "")
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"example.org/config"
+
+	"github.com/spf13/pflag"
+)
+
+// blockedSQL counts sessions currently waiting on another session's lock.
+const blockedSQL = `SELECT COUNT(*) AS blocked_count FROM sys.dm_exec_requests WHERE blocking_session_id <> 0`
+
+// runBlockedMode implements "check_mssql blocked ...": check for blocked
+// sessions via sys.dm_exec_requests.
+func runBlockedMode(args []string) {
+	fs := pflag.NewFlagSet("blocked", pflag.ExitOnError)
+	conn := bindConnectionFlags(fs)
+	warning := fs.StringP("warning", "w", "", "Warning threshold for the number of blocked sessions (Nagios range format)")
+	critical := fs.StringP("critical", "c", "", "Critical threshold for the number of blocked sessions")
+
+	fs.Usage = func() {
+		fmt.Printf(`check_mssql blocked - Checks for blocked sessions (sys.dm_exec_requests)
+
+Syntax: check_mssql blocked -H <server> -u <username> -p <password> [-d <database>] [-P <port>] [-t <timeout>] [-w <warning>] [-c <critical>] [-v] [-h] [-V]
+
+`)
+		fmt.Println("Parameters:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *conn.version {
+		printVersion()
+		os.Exit(OK)
+	}
+	if *conn.help {
+		fs.Usage()
+		os.Exit(OK)
+	}
+
+	if err := conn.apply(); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		fs.Usage()
+		os.Exit(UNKNOWN)
+	}
+	config.AppConfig.Blocked = config.BlockedOptions{Warning: *warning, Critical: *critical}
+
+	opts := config.QueryOptions{
+		SQL:      blockedSQL,
+		Rows:     "1",
+		ValueCol: "blocked_count",
+		Warning:  *warning,
+		Critical: *critical,
+	}
+	runCheck(opts, "")
+}
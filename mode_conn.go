@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"example.org/config"
+
+	"github.com/spf13/pflag"
+)
+
+// runConnMode implements "check_mssql conn ...": check how long it takes
+// to connect to and ping the server, with optional warning/critical
+// thresholds (in milliseconds).
+func runConnMode(args []string) {
+	fs := pflag.NewFlagSet("conn", pflag.ExitOnError)
+	conn := bindConnectionFlags(fs)
+	warning := fs.StringP("warning", "w", "", "Warning threshold for connection time in milliseconds (Nagios range format)")
+	critical := fs.StringP("critical", "c", "", "Critical threshold for connection time in milliseconds")
+
+	fs.Usage = func() {
+		fmt.Printf(`check_mssql conn - Checks how long it takes to connect to an MS-SQL server
+
+Syntax: check_mssql conn -H <server> -u <username> -p <password> [-d <database>] [-P <port>] [-t <timeout>] [-w <warning>] [-c <critical>] [-v] [-h] [-V]
+
+`)
+		fmt.Println("Parameters:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *conn.version {
+		printVersion()
+		os.Exit(OK)
+	}
+	if *conn.help {
+		fs.Usage()
+		os.Exit(OK)
+	}
+
+	if err := conn.apply(); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		fs.Usage()
+		os.Exit(UNKNOWN)
+	}
+	config.AppConfig.Conn = config.ConnOptions{Warning: *warning, Critical: *critical}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.AppConfig.Timeout)*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	db, err := connect(ctx)
+	elapsed := time.Since(start)
+	if err != nil {
+		fmt.Printf("SQL CRITICAL: %v\n", err)
+		os.Exit(CRITICAL)
+	}
+	defer db.Close()
+
+	ms := float64(elapsed.Milliseconds())
+	summary := fmt.Sprintf("connected in %.0fms", ms)
+	perfdata := fmt.Sprintf("connect_time_ms=%.0f;;;;", ms)
+
+	if *warning != "" || *critical != "" {
+		status, perfEntry, err := evaluateThreshold("connect_time_ms", ms, *warning, *critical)
+		if err != nil {
+			fmt.Printf("SQL UNKNOWN: %v\n", err)
+			os.Exit(UNKNOWN)
+		}
+		switch status {
+		case CRITICAL:
+			fmt.Printf("SQL CRITICAL: %s|%s\n", summary, perfEntry)
+			os.Exit(CRITICAL)
+		case WARNING:
+			fmt.Printf("SQL WARNING: %s|%s\n", summary, perfEntry)
+			os.Exit(WARNING)
+		}
+		perfdata = perfEntry
+	}
+
+	fmt.Printf("SQL OK: %s|%s\n", summary, perfdata)
+	os.Exit(OK)
+}
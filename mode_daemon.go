@@ -0,0 +1,334 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"example.org/config"
+
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+// checkDef describes one check loaded from a --checks YAML file. Server,
+// Port and Database override the daemon's own connection flags so several
+// checks can target different databases while still sharing the
+// username/password/auth mode they were started with.
+type checkDef struct {
+	Name     string `yaml:"name"`
+	Server   string `yaml:"server"`
+	Port     int    `yaml:"port"`
+	Database string `yaml:"database"`
+	Query    string `yaml:"query"`
+	ValueCol string `yaml:"value_col"`
+	Warning  string `yaml:"warning"`
+	Critical string `yaml:"critical"`
+	Interval string `yaml:"interval"`
+}
+
+// loadCheckDefs reads a list of checkDef from a YAML check config file.
+func loadCheckDefs(path string) ([]checkDef, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checks file: %v", err)
+	}
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		var defs []checkDef
+		if err := yaml.Unmarshal(data, &defs); err != nil {
+			return nil, fmt.Errorf("failed to parse checks file: %v", err)
+		}
+		return defs, nil
+	case ".toml":
+		return nil, fmt.Errorf("TOML check config is not yet supported; use a .yaml/.yml file")
+	default:
+		return nil, fmt.Errorf("unrecognized checks file extension %q (expected .yaml or .yml)", ext)
+	}
+}
+
+// checkStatus is the latest result of one running check.
+type checkStatus struct {
+	mu       sync.RWMutex
+	status   int
+	summary  string
+	perfdata string
+	value    *float64
+	lastRun  time.Time
+}
+
+func (cs *checkStatus) set(status int, summary, perfdata string, value *float64) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.status = status
+	cs.summary = summary
+	cs.perfdata = perfdata
+	cs.value = value
+	cs.lastRun = time.Now()
+}
+
+func (cs *checkStatus) snapshot() (status int, summary, perfdata string, value *float64, lastRun time.Time) {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	return cs.status, cs.summary, cs.perfdata, cs.value, cs.lastRun
+}
+
+// daemon runs the loaded checks on their own intervals against a shared
+// connPool and serves their latest results over HTTP.
+type daemon struct {
+	pool         *connPool
+	retries      int
+	retryBackoff time.Duration
+
+	mu      sync.RWMutex
+	results map[string]*checkStatus
+}
+
+func newDaemon(pool *connPool, retries int, retryBackoff time.Duration) *daemon {
+	return &daemon{pool: pool, retries: retries, retryBackoff: retryBackoff, results: make(map[string]*checkStatus)}
+}
+
+// run starts one polling goroutine per check definition; it returns once
+// ctx is cancelled.
+func (d *daemon) run(ctx context.Context, defs []checkDef) {
+	var wg sync.WaitGroup
+	for _, def := range defs {
+		interval, err := time.ParseDuration(def.Interval)
+		if err != nil || interval <= 0 {
+			interval = time.Minute
+		}
+
+		cs := &checkStatus{}
+		d.mu.Lock()
+		d.results[def.Name] = cs
+		d.mu.Unlock()
+
+		wg.Add(1)
+		go func(def checkDef, interval time.Duration, cs *checkStatus) {
+			defer wg.Done()
+			d.runOnce(ctx, def, cs)
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					d.runOnce(ctx, def, cs)
+				}
+			}
+		}(def, interval, cs)
+	}
+	wg.Wait()
+}
+
+// runOnce runs a single check and records its result.
+func (d *daemon) runOnce(ctx context.Context, def checkDef, cs *checkStatus) {
+	target := defaultTarget()
+	if def.Server != "" {
+		target.Server = def.Server
+	}
+	if def.Port != 0 {
+		target.Port = def.Port
+	}
+	if def.Database != "" {
+		target.Database = def.Database
+	}
+
+	checkCtx, cancel := context.WithTimeout(ctx, time.Duration(config.AppConfig.Timeout)*time.Second)
+	defer cancel()
+
+	var rr *rawRows
+	err := withRetry(checkCtx, d.retries, d.retryBackoff, func() error {
+		db, err := d.pool.get(target)
+		if err != nil {
+			return err
+		}
+		if err := db.PingContext(checkCtx); err != nil {
+			return err
+		}
+		rr, err = execute(checkCtx, db, def.Query, -1, config.AppConfig.Session)
+		return err
+	})
+	if err != nil {
+		cs.set(CRITICAL, err.Error(), "", nil)
+		return
+	}
+
+	result, err := buildQueryResult(rr, "", "", def.ValueCol, def.Warning, def.Critical)
+	if err != nil {
+		cs.set(UNKNOWN, err.Error(), "", nil)
+		return
+	}
+
+	status := OK
+	perfdata := result.Perfdata
+	if (def.Warning != "" || def.Critical != "") && result.Value != nil {
+		if st, perfEntry, err := evaluateThreshold(result.ValueCol, *result.Value, def.Warning, def.Critical); err == nil {
+			status = st
+			perfdata = strings.TrimSpace(perfdata + " " + perfEntry)
+		}
+	}
+	cs.set(status, result.Summary, perfdata, result.Value)
+}
+
+func statusText(status int) string {
+	switch status {
+	case OK:
+		return "OK"
+	case WARNING:
+		return "WARNING"
+	case CRITICAL:
+		return "CRITICAL"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// checkJSON is one check's result as served by GET /checks.
+type checkJSON struct {
+	Name       string `json:"name"`
+	Status     int    `json:"status"`
+	StatusText string `json:"status_text"`
+	Summary    string `json:"summary"`
+	Perfdata   string `json:"perfdata"`
+	LastRun    string `json:"last_run,omitempty"`
+}
+
+func (d *daemon) handleChecks(w http.ResponseWriter, r *http.Request) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	out := make([]checkJSON, 0, len(d.results))
+	for name, cs := range d.results {
+		status, summary, perfdata, _, lastRun := cs.snapshot()
+		entry := checkJSON{Name: name, Status: status, StatusText: statusText(status), Summary: summary, Perfdata: perfdata}
+		if !lastRun.IsZero() {
+			entry.LastRun = lastRun.Format(time.RFC3339)
+		}
+		out = append(out, entry)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(out)
+}
+
+func (d *daemon) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	fmt.Fprintln(w, "# HELP check_mssql_status Nagios-style status of the check (0=OK, 1=WARNING, 2=CRITICAL, 3=UNKNOWN)")
+	fmt.Fprintln(w, "# TYPE check_mssql_status gauge")
+	for name, cs := range d.results {
+		status, _, _, value, _ := cs.snapshot()
+		fmt.Fprintf(w, "check_mssql_status{check=%q} %d\n", name, status)
+		if value != nil {
+			fmt.Fprintf(w, "check_mssql_value{check=%q} %g\n", name, *value)
+		}
+	}
+}
+
+// runDaemonMode implements "check_mssql daemon ...": load a list of
+// checks from --checks and keep running, serving /metrics and /checks
+// over HTTP on --listen instead of exiting after a single result.
+func runDaemonMode(args []string) {
+	fs := pflag.NewFlagSet("daemon", pflag.ExitOnError)
+	conn := bindConnectionFlags(fs)
+
+	listen := fs.String("listen", ":9612", "Address to listen on for /metrics and /checks")
+	checksFile := fs.String("checks", "", "Path to a YAML file listing checks to run (name, query, warning, critical, value_col, interval)")
+	maxOpenConns := fs.Int("max-open-conns", 5, "Maximum open connections per (server, port, database)")
+	maxIdleConns := fs.Int("max-idle-conns", 2, "Maximum idle connections per (server, port, database)")
+	connMaxLifetime := fs.String("conn-max-lifetime", "5m", "Maximum lifetime of a pooled connection")
+	retries := fs.Int("retries", 3, "Number of retries for transient connection/query failures")
+	retryBackoff := fs.String("retry-backoff", "500ms", "Initial backoff between retries (doubles each attempt)")
+
+	fs.Usage = func() {
+		fmt.Printf(`check_mssql daemon - Runs configured checks on a schedule and serves their results over HTTP
+
+Syntax: check_mssql daemon -H <server> -u <username> -p <password> --checks=checks.yaml [--listen=:9612] [-d <database>] [-P <port>] [-t <timeout>] [-v] [-h] [-V]
+
+`)
+		fmt.Println("Parameters:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *conn.version {
+		printVersion()
+		os.Exit(OK)
+	}
+	if *conn.help {
+		fs.Usage()
+		os.Exit(OK)
+	}
+
+	if err := conn.apply(); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		fs.Usage()
+		os.Exit(UNKNOWN)
+	}
+	if *checksFile == "" {
+		fmt.Println("Error: missing required argument (checks)")
+		fs.Usage()
+		os.Exit(UNKNOWN)
+	}
+
+	connMaxLifetimeDuration, err := time.ParseDuration(*connMaxLifetime)
+	if err != nil {
+		fmt.Printf("Error: invalid --conn-max-lifetime: %v\n", err)
+		os.Exit(UNKNOWN)
+	}
+	retryBackoffDuration, err := time.ParseDuration(*retryBackoff)
+	if err != nil {
+		fmt.Printf("Error: invalid --retry-backoff: %v\n", err)
+		os.Exit(UNKNOWN)
+	}
+
+	config.AppConfig.Daemon = config.DaemonOptions{
+		Listen:          *listen,
+		ChecksFile:      *checksFile,
+		MaxOpenConns:    *maxOpenConns,
+		MaxIdleConns:    *maxIdleConns,
+		ConnMaxLifetime: *connMaxLifetime,
+		Retries:         *retries,
+		RetryBackoff:    *retryBackoff,
+	}
+
+	defs, err := loadCheckDefs(*checksFile)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(UNKNOWN)
+	}
+	if len(defs) == 0 {
+		fmt.Println("Error: checks file defines no checks")
+		os.Exit(UNKNOWN)
+	}
+
+	pool := newConnPool(*maxOpenConns, *maxIdleConns, connMaxLifetimeDuration)
+	defer pool.closeAll()
+
+	d := newDaemon(pool, *retries, retryBackoffDuration)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go d.run(ctx, defs)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", d.handleMetrics)
+	mux.HandleFunc("/checks", d.handleChecks)
+
+	fmt.Printf("check_mssql daemon listening on %s (%d checks loaded from %s)\n", *listen, len(defs), *checksFile)
+	if err := http.ListenAndServe(*listen, mux); err != nil {
+		fmt.Printf("SQL UNKNOWN: daemon exited: %v\n", err)
+		os.Exit(UNKNOWN)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"example.org/config"
+
+	"github.com/spf13/pflag"
+)
+
+// runQueryMode implements "check_mssql query ...": run an arbitrary
+// user-supplied query and check it with an optional regex and/or
+// warning/critical thresholds.
+func runQueryMode(args []string) {
+	fs := pflag.NewFlagSet("query", pflag.ExitOnError)
+	conn := bindConnectionFlags(fs)
+
+	query := fs.StringP("query", "q", "", "Query to execute")
+	regex := fs.StringP("regex", "r", "", "Regex pattern to match against output")
+	rowsFlag := fs.String("rows", "1", "Number of rows to fetch from the query, or 'all'")
+	perfdataCols := fs.String("perfdata-cols", "", "Comma-separated list of columns to emit as perfdata (default: all numeric columns)")
+	labelCol := fs.String("label-col", "", "Column whose value prefixes each row's perfdata labels when returning multiple rows")
+	warning := fs.StringP("warning", "w", "", "Warning threshold, in Nagios range format (e.g. 10, 10:, ~:10, 10:20, @10:20)")
+	critical := fs.StringP("critical", "c", "", "Critical threshold, in Nagios range format")
+	valueCol := fs.String("value-col", "", "Column to evaluate against --warning/--critical (default: first numeric column); with several rows, the row that evaluates worst is used")
+
+	fs.Usage = func() {
+		fmt.Printf(`check_mssql query - Runs a query against an MS-SQL server and returns the first row (or --rows of them)
+Returns CRITICAL if regex matches or errors occur. Numeric columns are emitted as Nagios perfdata.
+A simple SQL statement like "SELECT GETDATE()" verifies server responsiveness.
+
+Syntax: check_mssql query -H <server> -u <username> -p <password> -q <query> [-d <database>] [-P <port>] [-t <timeout>] [-r <regex>] [-w <warning>] [-c <critical>] [--value-col=col] [--rows=N|all] [--perfdata-cols=col1,col2] [--label-col=col] [-v] [-h] [-V]
+
+`)
+		fmt.Println("Parameters:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *conn.version {
+		printVersion()
+		os.Exit(OK)
+	}
+	if *conn.help {
+		fs.Usage()
+		os.Exit(OK)
+	}
+
+	if err := conn.apply(); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		fs.Usage()
+		os.Exit(UNKNOWN)
+	}
+	if *query == "" {
+		fmt.Println("Error: missing required argument (query)")
+		fs.Usage()
+		os.Exit(UNKNOWN)
+	}
+
+	opts := config.QueryOptions{
+		SQL:          *query,
+		Regex:        *regex,
+		Rows:         *rowsFlag,
+		PerfdataCols: *perfdataCols,
+		LabelCol:     *labelCol,
+		ValueCol:     *valueCol,
+		Warning:      *warning,
+		Critical:     *critical,
+	}
+	config.AppConfig.Query = opts
+
+	runCheck(opts, opts.Regex)
+}
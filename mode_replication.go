@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"example.org/config"
+
+	"github.com/spf13/pflag"
+)
+
+// replicationSQL reports the AlwaysOn / mirroring send and redo queue
+// sizes for every replicated database.
+const replicationSQL = `SELECT DB_NAME(database_id) AS database_name, log_send_queue_size, redo_queue_size FROM sys.dm_hadr_database_replica_states`
+
+// runReplicationMode implements "check_mssql replication ...": check
+// AlwaysOn / mirroring replication lag via
+// sys.dm_hadr_database_replica_states.
+func runReplicationMode(args []string) {
+	fs := pflag.NewFlagSet("replication", pflag.ExitOnError)
+	conn := bindConnectionFlags(fs)
+	warning := fs.StringP("warning", "w", "", "Warning threshold for log_send_queue_size (Nagios range format)")
+	critical := fs.StringP("critical", "c", "", "Critical threshold for log_send_queue_size")
+	valueCol := fs.String("value-col", "log_send_queue_size", "Column to evaluate against --warning/--critical")
+
+	fs.Usage = func() {
+		fmt.Printf(`check_mssql replication - Checks AlwaysOn / mirroring replication lag (sys.dm_hadr_database_replica_states)
+
+Syntax: check_mssql replication -H <server> -u <username> -p <password> [-d <database>] [-P <port>] [-t <timeout>] [-w <warning>] [-c <critical>] [--value-col=col] [-v] [-h] [-V]
+
+`)
+		fmt.Println("Parameters:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *conn.version {
+		printVersion()
+		os.Exit(OK)
+	}
+	if *conn.help {
+		fs.Usage()
+		os.Exit(OK)
+	}
+
+	if err := conn.apply(); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		fs.Usage()
+		os.Exit(UNKNOWN)
+	}
+	config.AppConfig.Replication = config.ReplicationOptions{Warning: *warning, Critical: *critical}
+
+	opts := config.QueryOptions{
+		SQL:      replicationSQL,
+		Rows:     "all",
+		LabelCol: "database_name",
+		ValueCol: *valueCol,
+		Warning:  *warning,
+		Critical: *critical,
+	}
+	runCheck(opts, "")
+}
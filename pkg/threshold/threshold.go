@@ -0,0 +1,134 @@
+// Package threshold implements Nagios plugin range specifications, as
+// described at https://nagios-plugins.org/doc/guidelines.html#THRESHOLDFORMAT,
+// so checks can evaluate a numeric value against -w/--warning and
+// -c/--critical flags.
+package threshold
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Range represents a parsed Nagios threshold range, e.g. "10", "10:",
+// "~:10", "10:20" or "@10:20".
+type Range struct {
+	Min     float64
+	Max     float64
+	MinInf  bool
+	MaxInf  bool
+	Inside  bool // true if the range was prefixed with "@" (alert when value falls inside min:max)
+	rawSpec string
+}
+
+// Parse parses a Nagios range specification into a Range.
+//
+// Supported forms:
+//
+//	10      alert if value < 0 or value > 10  (implicit "0:10")
+//	10:     alert if value < 10
+//	~:10    alert if value > 10
+//	10:20   alert if value < 10 or value > 20
+//	@10:20  alert if value >= 10 and value <= 20 (inverted)
+func Parse(spec string) (*Range, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, fmt.Errorf("empty threshold spec")
+	}
+
+	r := &Range{rawSpec: spec}
+
+	if strings.HasPrefix(spec, "@") {
+		r.Inside = true
+		spec = spec[1:]
+	}
+
+	if !strings.Contains(spec, ":") {
+		// Bare "N" means "0:N".
+		max, err := strconv.ParseFloat(spec, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid threshold %q: %v", r.rawSpec, err)
+		}
+		r.Min = 0
+		r.Max = max
+		return r, nil
+	}
+
+	parts := strings.SplitN(spec, ":", 2)
+	start, end := parts[0], parts[1]
+
+	switch start {
+	case "~":
+		r.MinInf = true
+	case "":
+		r.Min = 0
+	default:
+		min, err := strconv.ParseFloat(start, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid threshold %q: %v", r.rawSpec, err)
+		}
+		r.Min = min
+	}
+
+	if end == "" {
+		r.MaxInf = true
+	} else {
+		max, err := strconv.ParseFloat(end, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid threshold %q: %v", r.rawSpec, err)
+		}
+		r.Max = max
+	}
+
+	if !r.MinInf && !r.MaxInf && r.Min > r.Max {
+		return nil, fmt.Errorf("invalid threshold %q: min must not be greater than max", r.rawSpec)
+	}
+
+	return r, nil
+}
+
+// Evaluate reports whether value violates the range, i.e. whether a check
+// using this range as a warning or critical threshold should alert.
+func (r *Range) Evaluate(value float64) bool {
+	inside := (r.MinInf || value >= r.Min) && (r.MaxInf || value <= r.Max)
+	if r.Inside {
+		return inside
+	}
+	return !inside
+}
+
+// String returns the original spec the Range was parsed from.
+func (r *Range) String() string {
+	return r.rawSpec
+}
+
+// Bound returns the finite min and max of the range for use in perfdata
+// (e.g. "value;warn;crit;min;max"). Infinite bounds are rendered empty.
+func (r *Range) Bound() (min, max string) {
+	if r.MinInf {
+		min = ""
+	} else {
+		min = formatFloat(r.Min)
+	}
+	if r.MaxInf {
+		max = ""
+	} else {
+		max = formatFloat(r.Max)
+	}
+	return min, max
+}
+
+// FormatFloat renders f without scientific notation (unlike "%g", which
+// switches to exponential form above 1e6), so it's safe to drop straight
+// into Nagios perfdata.
+func FormatFloat(f float64) string {
+	return formatFloat(f)
+}
+
+func formatFloat(f float64) string {
+	if math.Trunc(f) == f {
+		return strconv.FormatInt(int64(f), 10)
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
@@ -0,0 +1,121 @@
+package threshold
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		spec       string
+		wantMin    float64
+		wantMax    float64
+		wantMinInf bool
+		wantMaxInf bool
+		wantInside bool
+	}{
+		{spec: "10", wantMin: 0, wantMax: 10},
+		{spec: "10:", wantMin: 10, wantMaxInf: true},
+		{spec: "10:20", wantMin: 10, wantMax: 20},
+		{spec: "~:10", wantMinInf: true, wantMax: 10},
+		{spec: "@10:20", wantMin: 10, wantMax: 20, wantInside: true},
+		{spec: " 10:20 ", wantMin: 10, wantMax: 20},
+	}
+	for _, tt := range tests {
+		r, err := Parse(tt.spec)
+		if err != nil {
+			t.Errorf("Parse(%q) returned error: %v", tt.spec, err)
+			continue
+		}
+		if r.Min != tt.wantMin || r.Max != tt.wantMax || r.MinInf != tt.wantMinInf || r.MaxInf != tt.wantMaxInf || r.Inside != tt.wantInside {
+			t.Errorf("Parse(%q) = %+v, want Min=%v Max=%v MinInf=%v MaxInf=%v Inside=%v",
+				tt.spec, r, tt.wantMin, tt.wantMax, tt.wantMinInf, tt.wantMaxInf, tt.wantInside)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []string{"", "abc", "10:5", "1:2:3", "@", "~"}
+	for _, spec := range tests {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("Parse(%q) returned no error, want one", spec)
+		}
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	tests := []struct {
+		spec  string
+		value float64
+		want  bool
+	}{
+		// Bare "10" == "0:10": alert outside [0,10].
+		{spec: "10", value: 5, want: false},
+		{spec: "10", value: 10, want: false},
+		{spec: "10", value: 11, want: true},
+		{spec: "10", value: -1, want: true},
+		// "10:": alert below 10.
+		{spec: "10:", value: 9, want: true},
+		{spec: "10:", value: 10, want: false},
+		{spec: "10:", value: 1000, want: false},
+		// "~:10": alert above 10.
+		{spec: "~:10", value: 10, want: false},
+		{spec: "~:10", value: 11, want: true},
+		{spec: "~:10", value: -1000, want: false},
+		// "10:20": alert outside [10,20].
+		{spec: "10:20", value: 15, want: false},
+		{spec: "10:20", value: 9, want: true},
+		{spec: "10:20", value: 21, want: true},
+		// "@10:20": alert inside [10,20].
+		{spec: "@10:20", value: 15, want: true},
+		{spec: "@10:20", value: 10, want: true},
+		{spec: "@10:20", value: 9, want: false},
+	}
+	for _, tt := range tests {
+		r, err := Parse(tt.spec)
+		if err != nil {
+			t.Fatalf("Parse(%q) returned error: %v", tt.spec, err)
+		}
+		if got := r.Evaluate(tt.value); got != tt.want {
+			t.Errorf("Parse(%q).Evaluate(%v) = %v, want %v", tt.spec, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestBound(t *testing.T) {
+	tests := []struct {
+		spec    string
+		wantMin string
+		wantMax string
+	}{
+		{spec: "10", wantMin: "0", wantMax: "10"},
+		{spec: "10:", wantMin: "10", wantMax: ""},
+		{spec: "~:10", wantMin: "", wantMax: "10"},
+		{spec: "10.5:20.25", wantMin: "10.5", wantMax: "20.25"},
+	}
+	for _, tt := range tests {
+		r, err := Parse(tt.spec)
+		if err != nil {
+			t.Fatalf("Parse(%q) returned error: %v", tt.spec, err)
+		}
+		min, max := r.Bound()
+		if min != tt.wantMin || max != tt.wantMax {
+			t.Errorf("Parse(%q).Bound() = (%q, %q), want (%q, %q)", tt.spec, min, max, tt.wantMin, tt.wantMax)
+		}
+	}
+}
+
+func TestFormatFloat(t *testing.T) {
+	tests := []struct {
+		value float64
+		want  string
+	}{
+		{value: 10, want: "10"},
+		{value: 10.5, want: "10.5"},
+		{value: 1234567, want: "1234567"},
+		{value: 0, want: "0"},
+		{value: -5.25, want: "-5.25"},
+	}
+	for _, tt := range tests {
+		if got := FormatFloat(tt.value); got != tt.want {
+			t.Errorf("FormatFloat(%v) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
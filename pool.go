@@ -0,0 +1,60 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// connPool keeps a single *sql.DB per (server, port, database) tuple so
+// daemon mode can service many checks without reconnecting for each one.
+type connPool struct {
+	mu              sync.Mutex
+	dbs             map[string]*sql.DB
+	maxOpenConns    int
+	maxIdleConns    int
+	connMaxLifetime time.Duration
+}
+
+func newConnPool(maxOpenConns, maxIdleConns int, connMaxLifetime time.Duration) *connPool {
+	return &connPool{
+		dbs:             make(map[string]*sql.DB),
+		maxOpenConns:    maxOpenConns,
+		maxIdleConns:    maxIdleConns,
+		connMaxLifetime: connMaxLifetime,
+	}
+}
+
+// get returns the pooled *sql.DB for target, opening one (but not
+// connecting yet — that happens lazily on first use) if none exists.
+func (p *connPool) get(target connTarget) (*sql.DB, error) {
+	key := fmt.Sprintf("%s:%d/%s", target.Server, target.Port, target.Database)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if db, ok := p.dbs[key]; ok {
+		return db, nil
+	}
+
+	connector, err := buildConnector(target)
+	if err != nil {
+		return nil, err
+	}
+	db := sql.OpenDB(connector)
+	db.SetMaxOpenConns(p.maxOpenConns)
+	db.SetMaxIdleConns(p.maxIdleConns)
+	db.SetConnMaxLifetime(p.connMaxLifetime)
+	p.dbs[key] = db
+	return db, nil
+}
+
+// closeAll closes every pooled connection.
+func (p *connPool) closeAll() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, db := range p.dbs {
+		db.Close()
+	}
+}
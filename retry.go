@@ -0,0 +1,28 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// withRetry calls fn, retrying up to retries times with exponential
+// backoff (starting at backoff, doubling each attempt) if it returns an
+// error. It stops early if ctx is done.
+func withRetry(ctx context.Context, retries int, backoff time.Duration, fn func() error) error {
+	wait := backoff
+	var err error
+	for attempt := 0; ; attempt++ {
+		if err = fn(); err == nil {
+			return nil
+		}
+		if attempt >= retries {
+			return err
+		}
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		wait *= 2
+	}
+}